@@ -0,0 +1,177 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import "sort"
+
+// Edge is a directed edge from one node to another.
+type Edge struct {
+	From, To ID
+}
+
+// OrderWithBrokenCycles returns a topological order for the DAG even when
+// it contains cycles, by minimally removing edges until it is acyclic.
+//
+// Tarjan's algorithm locates the strongly connected components of the
+// graph that actually contain a cycle: components of size > 1, plus any
+// size-1 component with a self-loop. Within each one, the edge whose source
+// has the highest in-SCC out-degree is greedily dropped (ties broken by the
+// lexicographically largest (from, to) pair), the component is
+// recalculated, and this repeats until no cycle remains in it. The dropped
+// edges are returned alongside the resulting order so a caller such as
+// "terramate run --allow-cycles" can warn about what it ignored.
+//
+// Validate keeps its strict all-or-nothing behavior; this is an explicit,
+// lenient alternative for callers that opt into it.
+func (d *DAG[V]) OrderWithBrokenCycles() (order []ID, broken []Edge, err error) {
+	graph := make(map[ID][]ID, len(d.dag))
+	for id, children := range d.dag {
+		clone := make([]ID, len(children))
+		copy(clone, children)
+		graph[id] = clone
+	}
+
+	for _, scc := range tarjanSCC(graph, d.IDs()) {
+		if len(scc) > 1 || hasSelfLoop(graph, scc[0]) {
+			broken = append(broken, breakCyclesInSCC(graph, sortedIds(scc))...)
+		}
+	}
+
+	sort.Slice(broken, func(i, j int) bool {
+		if broken[i].From != broken[j].From {
+			return broken[i].From < broken[j].From
+		}
+		return broken[i].To < broken[j].To
+	})
+
+	acyclic := &DAG[V]{dag: graph, values: d.values}
+	if _, err := acyclic.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return acyclic.Order(), broken, nil
+}
+
+// breakCyclesInSCC removes edges from graph, restricted to the members of
+// scc, until the component no longer contains a cycle, and returns the
+// removed edges.
+func breakCyclesInSCC(graph map[ID][]ID, scc idList) []Edge {
+	var removed []Edge
+	worklist := []idList{scc}
+
+	for len(worklist) > 0 {
+		cur := worklist[0]
+		worklist = worklist[1:]
+
+		members := make(map[ID]bool, len(cur))
+		for _, id := range cur {
+			members[id] = true
+		}
+
+		outdeg := map[ID]int{}
+		type candidate struct{ from, to ID }
+		var candidates []candidate
+		for _, id := range cur {
+			for _, child := range sortedIds(graph[id]) {
+				if members[child] {
+					outdeg[id]++
+					candidates = append(candidates, candidate{id, child})
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if outdeg[a.from] != outdeg[b.from] {
+				return outdeg[a.from] > outdeg[b.from]
+			}
+			if a.from != b.from {
+				return a.from > b.from
+			}
+			return a.to > b.to
+		})
+
+		victim := candidates[0]
+		graph[victim.from] = idList(graph[victim.from]).remove(victim.to)
+		removed = append(removed, Edge{From: victim.from, To: victim.to})
+
+		for _, sub := range tarjanSCC(graph, cur) {
+			if len(sub) > 1 || hasSelfLoop(graph, sub[0]) {
+				worklist = append(worklist, sortedIds(sub))
+			}
+		}
+	}
+
+	return removed
+}
+
+// tarjanSCC computes the strongly connected components of graph reachable
+// from ids, using Tarjan's algorithm. Traversal order is deterministic: ids
+// are visited in the given order and each node's edges in sorted order.
+func tarjanSCC(graph map[ID][]ID, ids []ID) [][]ID {
+	index := 0
+	indices := map[ID]int{}
+	lowlink := map[ID]int{}
+	onStack := map[ID]bool{}
+	var stack []ID
+	var sccs [][]ID
+
+	var strongconnect func(v ID)
+	strongconnect = func(v ID) {
+		indices[v] = index
+		lowlink[v] = index
+		index++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range sortedIds(graph[v]) {
+			if _, ok := indices[w]; !ok {
+				strongconnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] {
+				if indices[w] < lowlink[v] {
+					lowlink[v] = indices[w]
+				}
+			}
+		}
+
+		if lowlink[v] == indices[v] {
+			var scc []ID
+			for {
+				n := len(stack) - 1
+				w := stack[n]
+				stack = stack[:n]
+				onStack[w] = false
+				scc = append(scc, w)
+				if w == v {
+					break
+				}
+			}
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, id := range ids {
+		if _, ok := indices[id]; !ok {
+			strongconnect(id)
+		}
+	}
+	return sccs
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderWithBrokenCyclesNoCycle(t *testing.T) {
+	d, err := Parse(`
+		a > b
+		b > c
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	order, broken, err := d.OrderWithBrokenCycles()
+	if err != nil {
+		t.Fatalf("OrderWithBrokenCycles: %v", err)
+	}
+	if len(broken) != 0 {
+		t.Errorf("broken = %v, want none", broken)
+	}
+	if want := d.Order(); !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestOrderWithBrokenCyclesSimpleCycle(t *testing.T) {
+	// a -> b -> c -> a
+	d, err := Parse(`
+		a > b
+		b > c
+		c > a
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := d.Validate(); err == nil {
+		t.Fatalf("expected Validate to report a cycle")
+	}
+
+	order, broken, err := d.OrderWithBrokenCycles()
+	if err != nil {
+		t.Fatalf("OrderWithBrokenCycles: %v", err)
+	}
+	if len(broken) != 1 {
+		t.Fatalf("broken = %v, want exactly one edge removed", broken)
+	}
+	if len(order) != 3 {
+		t.Fatalf("order = %v, want all 3 nodes", order)
+	}
+
+	// Every node in the SCC has in-SCC out-degree 1, so ties are broken by
+	// the lexicographically largest (from, to) pair: c -> a.
+	want := Edge{From: "c", To: "a"}
+	if broken[0] != want {
+		t.Errorf("broken[0] = %v, want %v", broken[0], want)
+	}
+}
+
+func TestOrderWithBrokenCyclesSelfLoop(t *testing.T) {
+	// a -> a is a size-1 strongly connected component, but it is still a
+	// cycle and must be broken rather than passed through untouched.
+	d := New[any]()
+	if err := d.AddNode("a", nil, nil, []ID{"a"}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	if err := d.AddNode("b", nil, []ID{"a"}, nil); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+
+	order, broken, err := d.OrderWithBrokenCycles()
+	if err != nil {
+		t.Fatalf("OrderWithBrokenCycles: %v", err)
+	}
+	want := []Edge{{From: "a", To: "a"}}
+	if !reflect.DeepEqual(broken, want) {
+		t.Fatalf("broken = %v, want %v", broken, want)
+	}
+	if len(order) != 2 {
+		t.Fatalf("order = %v, want both nodes", order)
+	}
+}
+
+func TestOrderWithBrokenCyclesPicksHighestOutDegree(t *testing.T) {
+	// a -> b, a -> c, b -> a, c -> a: two independent 2-cycles sharing node
+	// a (a<->b and a<->c), so breaking both requires two removals. Each
+	// round picks the edge out of the highest in-SCC out-degree source,
+	// ties broken by the lexicographically largest (from, to) pair.
+	d, err := Parse(`
+		a > b, c
+		b > a
+		c > a
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	order, broken, err := d.OrderWithBrokenCycles()
+	if err != nil {
+		t.Fatalf("OrderWithBrokenCycles: %v", err)
+	}
+	want := []Edge{{From: "a", To: "c"}, {From: "b", To: "a"}}
+	if !reflect.DeepEqual(broken, want) {
+		t.Fatalf("broken = %v, want %v", broken, want)
+	}
+	if len(order) != 3 {
+		t.Fatalf("order = %v, want all 3 nodes", order)
+	}
+}
@@ -26,16 +26,20 @@ type (
 	// ID of nodes
 	ID string
 
-	// DAG is a Directed-Acyclic Graph
-	DAG struct {
+	// DAG is a Directed-Acyclic Graph of nodes holding a value of type V.
+	DAG[V any] struct {
 		dag    map[ID][]ID
-		values map[ID]interface{}
+		values map[ID]V
 		cycles map[ID]bool
 
 		validated bool
 	}
 )
 
+// AnyDAG is DAG[any], kept for one release so callers written against the
+// pre-generics interface{}-valued DAG keep compiling.
+type AnyDAG = DAG[any]
+
 // Errors returned by operations on the DAG.
 const (
 	ErrDuplicateNode errutil.Error = "duplicate node"
@@ -43,17 +47,17 @@ const (
 	ErrCycleDetected errutil.Error = "cycle detected"
 )
 
-// New creates a new empty Directed-Acyclic-Graph.
-func New() *DAG {
-	return &DAG{
+// New creates a new empty Directed-Acyclic-Graph holding values of type V.
+func New[V any]() *DAG[V] {
+	return &DAG[V]{
 		dag:    make(map[ID][]ID),
-		values: make(map[ID]interface{}),
+		values: make(map[ID]V),
 	}
 }
 
 // AddNode adds a new node to the dag. The lists of before and after
 // defines its edge nodes.
-func (d *DAG) AddNode(id ID, value interface{}, before, after []ID) error {
+func (d *DAG[V]) AddNode(id ID, value V, before, after []ID) error {
 	logger := log.With().
 		Str("action", "AddNode()").
 		Logger()
@@ -90,7 +94,7 @@ func (d *DAG) AddNode(id ID, value interface{}, before, after []ID) error {
 	return nil
 }
 
-func (d *DAG) addEdges(from ID, toids []ID) {
+func (d *DAG[V]) addEdges(from ID, toids []ID) {
 	for _, to := range toids {
 		log.Trace().
 			Str("action", "addEdges()").
@@ -101,12 +105,21 @@ func (d *DAG) addEdges(from ID, toids []ID) {
 	}
 }
 
-func (d *DAG) addEdge(from, to ID) {
+func (d *DAG[V]) addEdge(from, to ID) {
 	fromEdges, ok := d.dag[from]
 	if !ok {
 		panic("internal error: empty list of edges must exist at this point")
 	}
 
+	// to may not have been added on its own yet (e.g. it only appears in
+	// someone else's "after" list); register it the same way the "before"
+	// loop in AddNode does for predecessors, so d.IDs() and Validate() see
+	// every node reachable through an edge, not just the ones added via
+	// their own AddNode call.
+	if _, ok := d.dag[to]; !ok {
+		d.dag[to] = []ID{}
+	}
+
 	if !idList(fromEdges).contains(to) {
 		log.Trace().
 			Str("action", "addEdge()").
@@ -120,59 +133,135 @@ func (d *DAG) addEdge(from, to ID) {
 }
 
 // Validate the DAG looking for cycles.
-func (d *DAG) Validate() (reason string, err error) {
+//
+// Cycle detection uses Kahn's algorithm: in-degrees are computed for every
+// node, a queue is seeded with the zero-in-degree nodes and repeatedly
+// drained, decrementing the in-degree of each child and enqueuing it once it
+// reaches zero. If fewer nodes are popped than exist in the graph, the
+// remaining nodes (those with a non-zero in-degree) contain one or more
+// cycles; the nodes actually on a cycle are picked out of them with Tarjan's
+// algorithm and are the ones HasCycle reports.
+// This runs in O(V+E), unlike the exponential DFS this replaced.
+func (d *DAG[V]) Validate() (reason string, err error) {
 	d.cycles = make(map[ID]bool)
 	d.validated = true
 
-	for _, id := range d.IDs() {
+	ids := d.IDs()
+	indegree := make(map[ID]int, len(ids))
+	for _, id := range ids {
+		indegree[id] = 0
+	}
+	for _, id := range ids {
+		for _, child := range d.dag[id] {
+			indegree[child]++
+		}
+	}
+
+	queue := make([]ID, 0, len(ids))
+	for _, id := range ids {
+		if indegree[id] == 0 {
+			queue = append(queue, id)
+		}
+	}
+
+	popped := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		popped++
+
+		for _, child := range sortedIds(d.dag[id]) {
+			indegree[child]--
+			if indegree[child] == 0 {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	if popped == len(ids) {
 		log.Trace().
 			Str("action", "Validate()").
-			Str("id", string(id)).
-			Msg("Validate node.")
-		reason, err := d.validateNode(id, d.dag[id])
-		if err != nil {
-			return reason, err
+			Msg("No cycles found.")
+		return "", nil
+	}
+
+	// Everything left with a non-zero in-degree is reachable, directly or
+	// transitively, from some cycle, but not every such node is itself part
+	// of one (e.g. "c" in "a -> b -> a, b -> c" is merely reachable from the
+	// cyclic "b"). Narrow down to the residual subgraph, then to its strongly
+	// connected components, to find the nodes that truly participate in a
+	// cycle: an SCC of size > 1, or a size-1 SCC with a self-loop.
+	pending := map[ID]bool{}
+	for _, id := range ids {
+		if indegree[id] > 0 {
+			pending[id] = true
+		}
+	}
+	residual := map[ID][]ID{}
+	for id := range pending {
+		for _, child := range d.dag[id] {
+			if pending[child] {
+				residual[id] = append(residual[id], child)
+			}
 		}
 	}
-	return "", nil
-}
 
-func (d *DAG) validateNode(id ID, children []ID) (string, error) {
-	log.Trace().
-		Str("action", "validateNode()").
-		Str("id", string(id)).
-		Msg("Check if has cycle.")
-	found, reason := d.hasCycle([]ID{id}, children, fmt.Sprintf("%s ->", id))
-	if found {
-		d.cycles[id] = true
-		return reason, errutil.Chain(
-			ErrCycleDetected,
-			fmt.Errorf("checking node id %q", id),
-		)
+	pendingIDs := make(idList, 0, len(pending))
+	for id := range pending {
+		pendingIDs = append(pendingIDs, id)
+	}
+	sort.Sort(pendingIDs)
+	for _, scc := range tarjanSCC(residual, pendingIDs) {
+		if len(scc) > 1 || hasSelfLoop(residual, scc[0]) {
+			for _, id := range scc {
+				d.cycles[id] = true
+			}
+		}
+	}
+
+	// Recover a single witness cycle, restricted to the (small) residual
+	// subgraph, to preserve the historical ErrCycleDetected reason string.
+	for _, id := range ids {
+		if !d.cycles[id] {
+			continue
+		}
+		log.Trace().
+			Str("action", "Validate()").
+			Str("id", string(id)).
+			Msg("Check if has cycle.")
+		found, witness := d.hasCycle(residual, []ID{id}, residual[id], fmt.Sprintf("%s ->", id))
+		if found {
+			return witness, errutil.Chain(
+				ErrCycleDetected,
+				fmt.Errorf("checking node id %q", id),
+			)
+		}
 	}
 
-	return "", nil
+	return "", errutil.Chain(
+		ErrCycleDetected,
+		fmt.Errorf("checking residual graph"),
+	)
 }
 
-func (d *DAG) hasCycle(branch []ID, children []ID, reason string) (bool, string) {
+func (d *DAG[V]) hasCycle(graph map[ID][]ID, branch []ID, children []ID, reason string) (bool, string) {
 	for _, id := range branch {
 		log.Trace().
 			Str("action", "hasCycle()").
 			Str("id", string(id)).
 			Msg("Check if id is present in children.")
 		if idList(children).contains(id) {
-			d.cycles[id] = true
 			return true, fmt.Sprintf("%s %s", reason, id)
 		}
 	}
 
 	for _, tid := range sortedIds(children) {
-		tlist := d.dag[tid]
+		tlist := graph[tid]
 		log.Trace().
 			Str("action", "hasCycle()").
 			Str("id", string(tid)).
 			Msg("Check if id has cycle.")
-		found, reason := d.hasCycle(append(branch, tid), tlist, fmt.Sprintf("%s %s ->", reason, tid))
+		found, reason := d.hasCycle(graph, append(branch, tid), tlist, fmt.Sprintf("%s %s ->", reason, tid))
 		if found {
 			return true, reason
 		}
@@ -182,7 +271,7 @@ func (d *DAG) hasCycle(branch []ID, children []ID, reason string) (bool, string)
 }
 
 // IDs returns the sorted list of node ids.
-func (d *DAG) IDs() []ID {
+func (d *DAG[V]) IDs() []ID {
 	idlist := make(idList, 0, len(d.dag))
 	for id := range d.dag {
 		idlist = append(idlist, id)
@@ -196,21 +285,100 @@ func (d *DAG) IDs() []ID {
 }
 
 // Node returns the node with the given id.
-func (d *DAG) Node(id ID) (interface{}, error) {
+func (d *DAG[V]) Node(id ID) (V, error) {
 	v, ok := d.values[id]
 	if !ok {
-		return nil, ErrNodeNotFound
+		var zero V
+		return zero, ErrNodeNotFound
 	}
 	return v, nil
 }
 
 // ChildrenOf returns the list of node ids that are children of the given id.
-func (d *DAG) ChildrenOf(id ID) []ID {
+func (d *DAG[V]) ChildrenOf(id ID) []ID {
 	return d.dag[id]
 }
 
+// Transpose returns a new DAG with every edge reversed. The new DAG has its
+// own value map holding the receiver's node values, so a pointer value still
+// refers to the same data.
+func (d *DAG[V]) Transpose() *DAG[V] {
+	t := New[V]()
+	for id, value := range d.values {
+		t.values[id] = value
+	}
+	for id := range d.dag {
+		if _, ok := t.dag[id]; !ok {
+			t.dag[id] = []ID{}
+		}
+	}
+	for id, children := range d.dag {
+		for _, child := range children {
+			if _, ok := t.dag[child]; !ok {
+				t.dag[child] = []ID{}
+			}
+			t.dag[child] = append(t.dag[child], id)
+		}
+	}
+	return t
+}
+
+// AncestorsOf returns every node with a transitive path to id; Order places
+// these nodes after id. Like DescendantsOf, the result is sorted
+// lexicographically for a deterministic return value, not in topological
+// order.
+func (d *DAG[V]) AncestorsOf(id ID) []ID {
+	return d.Transpose().bfsFrom(id)
+}
+
+// DescendantsOf returns every node transitively reachable from id; Order
+// places these nodes before id.
+func (d *DAG[V]) DescendantsOf(id ID) []ID {
+	return d.bfsFrom(id)
+}
+
+// Reachable reports whether to can be reached from "from" by following
+// edges forward. A node is always reachable from itself.
+func (d *DAG[V]) Reachable(from, to ID) bool {
+	if from == to {
+		return true
+	}
+
+	for _, id := range d.bfsFrom(from) {
+		if id == to {
+			return true
+		}
+	}
+	return false
+}
+
+// bfsFrom returns every node reachable from id, excluding id itself, sorted
+// for deterministic output.
+func (d *DAG[V]) bfsFrom(id ID) []ID {
+	visited := map[ID]struct{}{id: {}}
+	queue := []ID{id}
+	result := idList{}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for _, child := range d.dag[cur] {
+			if _, ok := visited[child]; ok {
+				continue
+			}
+			visited[child] = struct{}{}
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+
+	sort.Sort(result)
+	return result
+}
+
 // HasCycle returns true if the DAG has a cycle.
-func (d *DAG) HasCycle(id ID) bool {
+func (d *DAG[V]) HasCycle(id ID) bool {
 	if !d.validated {
 		log.Trace().
 			Str("action", "HasCycle()").
@@ -227,7 +395,7 @@ func (d *DAG) HasCycle(id ID) bool {
 
 // Order returns the topological order of the DAG. The node ids are
 // lexicographic sorted whenever possible to give a consistent output.
-func (d *DAG) Order() []ID {
+func (d *DAG[V]) Order() []ID {
 	order := []ID{}
 	visited := map[ID]struct{}{}
 	for _, id := range d.IDs() {
@@ -255,7 +423,7 @@ func (d *DAG) Order() []ID {
 	return order
 }
 
-func (d *DAG) walkFrom(id ID, do func(id ID)) {
+func (d *DAG[V]) walkFrom(id ID, do func(id ID)) {
 	children := d.dag[id]
 	for _, tid := range sortedIds(children) {
 		log.Trace().
@@ -281,6 +449,11 @@ func sortedIds(ids []ID) idList {
 	return idlist
 }
 
+// hasSelfLoop reports whether id has an edge to itself in graph.
+func hasSelfLoop(graph map[ID][]ID, id ID) bool {
+	return idList(graph[id]).contains(id)
+}
+
 type idList []ID
 
 func (ids idList) contains(other ID) bool {
@@ -293,6 +466,17 @@ func (ids idList) contains(other ID) bool {
 	return false
 }
 
+// remove returns a new idList with other removed, if present.
+func (ids idList) remove(other ID) idList {
+	out := make(idList, 0, len(ids))
+	for _, id := range ids {
+		if id != other {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
 func (ids idList) Len() int           { return len(ids) }
 func (ids idList) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
 func (ids idList) Less(i, j int) bool { return ids[i] < ids[j] }
@@ -0,0 +1,103 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"testing"
+)
+
+// buildPathologicalDAG builds a deep+wide DAG of roughly depth*width nodes,
+// where every node of the previous layer has an edge to each node of a layer.
+// This fan-in shape is what made the old exponential DFS blow up: the same
+// nodes get revisited through combinatorially many paths.
+func buildPathologicalDAG(depth, width int) *DAG[any] {
+	d := New[any]()
+	var prevLayer []ID
+	for layer := 0; layer < depth; layer++ {
+		curLayer := make([]ID, 0, width)
+		for n := 0; n < width; n++ {
+			id := ID(fmt.Sprintf("n%d-%d", layer, n))
+			curLayer = append(curLayer, id)
+			if err := d.AddNode(id, nil, prevLayer, nil); err != nil {
+				panic(err)
+			}
+		}
+		prevLayer = curLayer
+	}
+	return d
+}
+
+// BenchmarkNodeTypedVsAssertion compares reading node values out of a typed
+// DAG[string] against the old pattern of storing them in an AnyDAG (DAG[any])
+// and type-asserting them back out at every call site, to show the generic
+// DAG removes that assertion cost entirely.
+func BenchmarkNodeTypedVsAssertion(b *testing.B) {
+	const n = 1000
+
+	typed := New[string]()
+	untyped := New[any]()
+	for i := 0; i < n; i++ {
+		id := ID(fmt.Sprintf("n%d", i))
+		if err := typed.AddNode(id, string(id), nil, nil); err != nil {
+			b.Fatalf("AddNode: %v", err)
+		}
+		if err := untyped.AddNode(id, string(id), nil, nil); err != nil {
+			b.Fatalf("AddNode: %v", err)
+		}
+	}
+
+	b.Run("typed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			id := ID(fmt.Sprintf("n%d", i%n))
+			if _, err := typed.Node(id); err != nil {
+				b.Fatalf("Node: %v", err)
+			}
+		}
+	})
+
+	b.Run("interface{}+assertion", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			id := ID(fmt.Sprintf("n%d", i%n))
+			v, err := untyped.Node(id)
+			if err != nil {
+				b.Fatalf("Node: %v", err)
+			}
+			if _, ok := v.(string); !ok {
+				b.Fatalf("Node(%s) did not hold a string", id)
+			}
+		}
+	})
+}
+
+func BenchmarkValidatePathological(b *testing.B) {
+	for _, size := range []struct{ depth, width int }{
+		{depth: 10, width: 10},
+		{depth: 20, width: 10},
+		{depth: 40, width: 10},
+	} {
+		size := size
+		b.Run(fmt.Sprintf("depth=%d/width=%d", size.depth, size.width), func(b *testing.B) {
+			d := buildPathologicalDAG(size.depth, size.width)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				d.validated = false
+				if _, err := d.Validate(); err != nil {
+					b.Fatalf("unexpected cycle: %v", err)
+				}
+			}
+		})
+	}
+}
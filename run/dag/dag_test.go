@@ -0,0 +1,97 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateNoCycle(t *testing.T) {
+	d, err := Parse(`
+		a > b
+		b > c
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if _, err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for _, id := range []ID{"a", "b", "c"} {
+		if d.HasCycle(id) {
+			t.Errorf("HasCycle(%s) = true, want false", id)
+		}
+	}
+}
+
+func TestValidateOnlyMarksCycleMembers(t *testing.T) {
+	// a -> b -> a is a cycle; c is merely reachable from b and is not
+	// itself cyclic, so HasCycle(c) must stay false.
+	d, err := Parse(`
+		a > b
+		b > a, c
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if _, err := d.Validate(); !errors.Is(err, ErrCycleDetected) {
+		t.Fatalf("Validate: %v, want ErrCycleDetected", err)
+	}
+
+	for _, id := range []ID{"a", "b"} {
+		if !d.HasCycle(id) {
+			t.Errorf("HasCycle(%s) = false, want true", id)
+		}
+	}
+	if d.HasCycle("c") {
+		t.Errorf("HasCycle(c) = true, want false: c is not part of any cycle")
+	}
+}
+
+func TestValidateSuccessorOnlyNodes(t *testing.T) {
+	// x1, x2 and x3 are only ever referenced via p's "after" list, never
+	// added on their own: they must still be counted as real nodes so
+	// Validate's Kahn's-algorithm pop count lines up with its node count.
+	d := New[any]()
+	if err := d.AddNode("p", nil, nil, []ID{"x1", "x2", "x3"}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+
+	if _, err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v, want no cycle", err)
+	}
+	for _, id := range []ID{"p", "x1", "x2", "x3"} {
+		if d.HasCycle(id) {
+			t.Errorf("HasCycle(%s) = true, want false", id)
+		}
+	}
+}
+
+func TestValidateSelfLoop(t *testing.T) {
+	d := New[any]()
+	if err := d.AddNode("a", nil, nil, []ID{"a"}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+
+	if _, err := d.Validate(); !errors.Is(err, ErrCycleDetected) {
+		t.Fatalf("Validate: %v, want ErrCycleDetected", err)
+	}
+	if !d.HasCycle("a") {
+		t.Errorf("HasCycle(a) = false, want true")
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/madlambda/spells/errutil"
+)
+
+// Graph is an immutable snapshot of a DAG. Unlike DAG, a Graph is never
+// mutated in place: use DAG.Snapshot to freeze one and Graph.Update to
+// derive a new one. Order, ImportedBy and AncestorsOf are precomputed at
+// snapshot time, so repeated queries against a Graph are O(1).
+type Graph[V any] struct {
+	dag    map[ID][]ID
+	values map[ID]V
+
+	order      []ID
+	importedBy map[ID][]ID
+	ancestors  map[ID][]ID
+}
+
+// NodeChange describes how a single node changes between two Graph
+// snapshots, for use with Graph.Update. A nil Value deletes the node;
+// otherwise Before and After replace the node's edges the same way
+// AddNode's before and after parameters do.
+type NodeChange[V any] struct {
+	Value         *V
+	Before, After []ID
+}
+
+// Snapshot freezes the current state of d into an immutable Graph.
+func (d *DAG[V]) Snapshot() (*Graph[V], error) {
+	dag := cloneAdjacency(d.dag)
+	values := cloneValues(d.values)
+	base := &DAG[V]{dag: dag, values: values}
+
+	reason, err := base.Validate()
+	if err != nil {
+		return nil, errutil.Chain(
+			ErrCycleDetected,
+			fmt.Errorf("snapshotting graph: %s", reason),
+		)
+	}
+
+	g := &Graph[V]{dag: dag, values: values}
+	g.importedBy = buildImportedBy(dag)
+	g.order = base.Order()
+	g.ancestors = computeAncestors(base)
+	return g, nil
+}
+
+// Update derives a new Graph from g by applying changes, keyed by node id.
+// Adjacency slices untouched by the delta are shared with g rather than
+// copied. A delta that introduces a cycle is rejected: g is returned
+// unchanged together with the error. When the delta adds or deletes no node
+// and adds or removes no edge, the new Graph reuses g's cached Order(),
+// ImportedBy and AncestorsOf instead of recomputing them; a change that lists
+// Before or After edges, or drops edges the node had, counts as changing
+// edges even when the resulting edges are the same.
+func (g *Graph[V]) Update(changes map[ID]*NodeChange[V]) (*Graph[V], error) {
+	dag := cloneAdjacency(g.dag)
+	values := cloneValues(g.values)
+	base := &DAG[V]{dag: dag, values: values}
+
+	ids := make(idList, 0, len(changes))
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	sort.Sort(ids)
+
+	// Removals run as their own pass, against the graph as it stood before
+	// this Update, so that e.g. swapping edges between two nodes in the
+	// same call doesn't have one node's removal undo the other's insert.
+	edgesChanged := false
+	for _, id := range ids {
+		// Adding or deleting a node changes the node set the cached Order(),
+		// importedBy and ancestors cover even when no edge moves.
+		_, existed := g.dag[id]
+		deleted := changes[id] == nil || changes[id].Value == nil
+		if existed == deleted {
+			edgesChanged = true
+		}
+		if removeNode(base, g, id) {
+			edgesChanged = true
+		}
+	}
+
+	for _, id := range ids {
+		change := changes[id]
+		if change == nil || change.Value == nil {
+			continue
+		}
+
+		for _, bid := range change.Before {
+			detach(base, bid)
+		}
+		if err := base.AddNode(id, *change.Value, change.Before, change.After); err != nil {
+			return g, err
+		}
+		if len(change.Before) > 0 || len(change.After) > 0 {
+			edgesChanged = true
+		}
+	}
+
+	reason, err := base.Validate()
+	if err != nil {
+		return g, errutil.Chain(
+			ErrCycleDetected,
+			fmt.Errorf("updating graph: %s", reason),
+		)
+	}
+
+	next := &Graph[V]{dag: dag, values: values}
+	if edgesChanged {
+		next.importedBy = buildImportedBy(dag)
+		next.order = base.Order()
+		next.ancestors = computeAncestors(base)
+	} else {
+		next.importedBy = g.importedBy
+		next.order = g.order
+		next.ancestors = g.ancestors
+	}
+	return next, nil
+}
+
+// Order returns the cached topological order of the graph.
+func (g *Graph[V]) Order() []ID {
+	return g.order
+}
+
+// ImportedBy returns the direct predecessors of id: the nodes that list id
+// as one of their edges.
+func (g *Graph[V]) ImportedBy(id ID) []ID {
+	return g.importedBy[id]
+}
+
+// AncestorsOf returns the cached transitive predecessors of id.
+func (g *Graph[V]) AncestorsOf(id ID) []ID {
+	return g.ancestors[id]
+}
+
+// ChildrenOf returns the list of node ids that are children of the given id.
+func (g *Graph[V]) ChildrenOf(id ID) []ID {
+	return g.dag[id]
+}
+
+// Node returns the node with the given id.
+func (g *Graph[V]) Node(id ID) (V, error) {
+	v, ok := g.values[id]
+	if !ok {
+		var zero V
+		return zero, ErrNodeNotFound
+	}
+	return v, nil
+}
+
+func cloneAdjacency(dag map[ID][]ID) map[ID][]ID {
+	out := make(map[ID][]ID, len(dag))
+	for id, children := range dag {
+		out[id] = children
+	}
+	return out
+}
+
+func cloneValues[V any](values map[ID]V) map[ID]V {
+	out := make(map[ID]V, len(values))
+	for id, v := range values {
+		out[id] = v
+	}
+	return out
+}
+
+func buildImportedBy(dag map[ID][]ID) map[ID][]ID {
+	out := make(map[ID][]ID, len(dag))
+	for id := range dag {
+		if _, ok := out[id]; !ok {
+			out[id] = nil
+		}
+	}
+	for id, children := range dag {
+		for _, child := range children {
+			out[child] = append(out[child], id)
+		}
+	}
+	return out
+}
+
+// computeAncestors transposes base once and walks the transpose from every
+// node, instead of re-transposing the whole graph once per node the way
+// calling base.AncestorsOf(id) in the loop would.
+func computeAncestors[V any](base *DAG[V]) map[ID][]ID {
+	t := base.Transpose()
+	out := make(map[ID][]ID, len(base.dag))
+	for id := range base.dag {
+		out[id] = t.bfsFrom(id)
+	}
+	return out
+}
+
+// detach clones id's adjacency slice in base so it can be appended to
+// without mutating a slice that may still be shared with another Graph.
+func detach[V any](base *DAG[V], id ID) {
+	children, ok := base.dag[id]
+	if !ok {
+		return
+	}
+	clone := make([]ID, len(children))
+	copy(clone, children)
+	base.dag[id] = clone
+}
+
+// removeNode deletes id's value and outgoing edges from base, and drops id
+// from every node that, as of g, listed it as a child. It reports whether
+// any edge was actually removed, which is false only for a node that had no
+// edges in or out.
+func removeNode[V any](base *DAG[V], g *Graph[V], id ID) bool {
+	edgesRemoved := false
+
+	delete(base.values, id)
+
+	if children, ok := base.dag[id]; ok {
+		if len(children) > 0 {
+			edgesRemoved = true
+		}
+		delete(base.dag, id)
+	}
+
+	for _, from := range g.importedBy[id] {
+		children, ok := base.dag[from]
+		if !ok {
+			continue
+		}
+		filtered := idList(children).remove(id)
+		if len(filtered) != len(children) {
+			base.dag[from] = filtered
+			edgesRemoved = true
+		}
+	}
+
+	return edgesRemoved
+}
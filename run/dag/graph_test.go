@@ -0,0 +1,161 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func buildValuedDAG(t *testing.T, edges map[ID][]ID) *DAG[string] {
+	t.Helper()
+	d := New[string]()
+	for _, id := range (idList{"a", "b", "c", "d"}) {
+		if err := d.AddNode(id, string(id), nil, edges[id]); err != nil {
+			t.Fatalf("AddNode(%s): %v", id, err)
+		}
+	}
+	return d
+}
+
+// ptr returns a pointer to v, for building NodeChange.Value fields inline.
+func ptr[V any](v V) *V {
+	return &v
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	d := buildValuedDAG(t, map[ID][]ID{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+	})
+
+	g, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	if want := d.Order(); !reflect.DeepEqual(g.Order(), want) {
+		t.Errorf("Order() = %v, want %v (same as DAG.Order())", g.Order(), want)
+	}
+	if want := (idList{"a", "b", "c"}); !reflect.DeepEqual(idList(g.AncestorsOf("d")), want) {
+		t.Errorf("AncestorsOf(d) = %v, want %v", g.AncestorsOf("d"), want)
+	}
+	if want := (idList{"a"}); !reflect.DeepEqual(idList(g.ImportedBy("b")), want) {
+		t.Errorf("ImportedBy(b) = %v, want %v", g.ImportedBy("b"), want)
+	}
+}
+
+func TestGraphUpdateReusesOrderWhenEdgePreserving(t *testing.T) {
+	d := New[string]()
+	if err := d.AddNode("a", "v1", nil, nil); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+
+	g, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	g2, err := g.Update(map[ID]*NodeChange[string]{
+		"a": {Value: ptr("v2")},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if v, _ := g2.Node("a"); v != "v2" {
+		t.Errorf("Node(a) = %v, want v2", v)
+	}
+	got := reflect.ValueOf(g2.Order()).Pointer()
+	want := reflect.ValueOf(g.Order()).Pointer()
+	if got != want {
+		t.Errorf("Update() did not reuse the previous Order() slice")
+	}
+
+	// A value-only change must reuse importedBy/ancestors too, the same way
+	// it reuses Order(), instead of paying for a full rebuild.
+	if gotIB, wantIB := reflect.ValueOf(g2.importedBy).Pointer(), reflect.ValueOf(g.importedBy).Pointer(); gotIB != wantIB {
+		t.Errorf("Update() did not reuse the previous importedBy map")
+	}
+	if gotA, wantA := reflect.ValueOf(g2.ancestors).Pointer(), reflect.ValueOf(g.ancestors).Pointer(); gotA != wantA {
+		t.Errorf("Update() did not reuse the previous ancestors map")
+	}
+}
+
+func TestGraphUpdateAddRemoveNode(t *testing.T) {
+	d, err := Parse(`
+		a > b
+		b > c
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	g, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	g2, err := g.Update(map[ID]*NodeChange[any]{
+		"c": nil, // delete c
+		"d": {Value: ptr[any]("new"), Before: []ID{"b"}},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if _, err := g2.Node("c"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Node(c) = %v, want ErrNodeNotFound", err)
+	}
+	if want := (idList{"d"}); !reflect.DeepEqual(idList(g2.ChildrenOf("b")), want) {
+		t.Errorf("ChildrenOf(b) = %v, want %v", g2.ChildrenOf("b"), want)
+	}
+
+	// g is untouched by the update.
+	if want := (idList{"c"}); !reflect.DeepEqual(idList(g.ChildrenOf("b")), want) {
+		t.Errorf("original graph mutated: ChildrenOf(b) = %v, want %v", g.ChildrenOf("b"), want)
+	}
+}
+
+func TestGraphUpdateRejectsCycle(t *testing.T) {
+	d := New[string]()
+	if err := d.AddNode("a", "va", nil, []ID{"b"}); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+	if err := d.AddNode("b", "vb", nil, nil); err != nil {
+		t.Fatalf("AddNode: %v", err)
+	}
+
+	g, err := d.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	g2, err := g.Update(map[ID]*NodeChange[string]{
+		"a": {Value: ptr("va"), After: []ID{"b"}},
+		"b": {Value: ptr("vb"), After: []ID{"a"}},
+	})
+	if err == nil {
+		t.Fatalf("Update: expected cycle error, got nil (g2=%v)", g2)
+	}
+	if !errors.Is(err, ErrCycleDetected) {
+		t.Errorf("Update error = %v, want ErrCycleDetected", err)
+	}
+	if g2 != g {
+		t.Errorf("Update() on error should return the receiver unchanged")
+	}
+}
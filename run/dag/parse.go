@@ -0,0 +1,156 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/madlambda/spells/errutil"
+)
+
+// Parse builds a DAG from a small textual description, one declaration per
+// line:
+//
+//	NODE < PRED1, PRED2   # PRED1 and PRED2 are predecessors of NODE
+//	NODE > SUCC1, SUCC2   # NODE is a predecessor of SUCC1 and SUCC2
+//	NODE                  # a bare node with no edges
+//
+// Order places a node after every node its edges lead to, so "a > b" orders
+// b before a, and "a < b" orders a before b.
+//
+// Blank lines and anything following a "#" are ignored. Nodes are created in
+// first-appearance order. A node that is only referenced in another node's
+// list, and never declared on a line of its own, is auto-created with a nil
+// value, so Node finds it like any declared node. Declaring the same node id
+// twice is an error (ErrDuplicateNode).
+//
+// Parse is meant for tests and debugging, e.g. writing out an expected stack
+// ordering as plain text instead of a series of AddNode calls. The textual
+// format carries no values, so Parse always returns a DAG[any] with every
+// node's value left nil.
+func Parse(src string) (*DAG[any], error) {
+	d := New[any]()
+	declared := map[ID]bool{}
+	var referenced []ID
+
+	for i, line := range strings.Split(src, "\n") {
+		lineno := i + 1
+
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		id, before, after, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("dag: parsing line %d: %w", lineno, err)
+		}
+
+		if declared[id] {
+			return nil, errutil.Chain(
+				ErrDuplicateNode,
+				fmt.Errorf("parsing line %d: node %q already declared", lineno, id),
+			)
+		}
+		declared[id] = true
+		referenced = append(referenced, before...)
+		referenced = append(referenced, after...)
+
+		if err := d.AddNode(id, nil, before, after); err != nil {
+			return nil, fmt.Errorf("dag: parsing line %d: %w", lineno, err)
+		}
+	}
+
+	// AddNode auto-creates a d.dag adjacency entry for a referenced id on
+	// both the "before" and "after" side of an edge, but never a d.values
+	// entry, so check values here rather than d.dag to find the ones that
+	// still need a node of their own.
+	for _, id := range referenced {
+		if _, ok := d.values[id]; !ok {
+			if err := d.AddNode(id, nil, nil, nil); err != nil {
+				return nil, fmt.Errorf("dag: auto-creating node %q: %w", id, err)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func parseLine(line string) (id ID, before, after []ID, err error) {
+	op := ""
+	idx := strings.IndexAny(line, "<>")
+	head := line
+	if idx >= 0 {
+		op = string(line[idx])
+		head = line[:idx]
+	}
+
+	head = strings.TrimSpace(head)
+	if head == "" {
+		return "", nil, nil, fmt.Errorf("missing node id in %q", line)
+	}
+	id = ID(head)
+
+	if idx < 0 {
+		return id, nil, nil, nil
+	}
+
+	list := parseIDList(line[idx+1:])
+	switch op {
+	case "<":
+		before = list
+	case ">":
+		after = list
+	}
+	return id, before, after, nil
+}
+
+func parseIDList(s string) []ID {
+	fields := strings.Split(s, ",")
+	ids := make([]ID, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		ids = append(ids, ID(f))
+	}
+	return ids
+}
+
+// String formats the DAG back into the syntax understood by Parse, with
+// nodes in IDs() order and each node's edges sorted, so the output is
+// deterministic and round-trips through Parse.
+func (d *DAG[V]) String() string {
+	var b strings.Builder
+	for _, id := range d.IDs() {
+		children := sortedIds(d.dag[id])
+		if len(children) == 0 {
+			fmt.Fprintf(&b, "%s\n", id)
+			continue
+		}
+
+		strs := make([]string, len(children))
+		for i, c := range children {
+			strs[i] = string(c)
+		}
+		fmt.Fprintf(&b, "%s > %s\n", id, strings.Join(strs, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseString(t *testing.T) {
+	type testcase struct {
+		name string
+		src  string
+		want string
+	}
+
+	tcases := []testcase{
+		{
+			name: "single node",
+			src:  "a",
+			want: "a\n",
+		},
+		{
+			name: "predecessors",
+			src:  "a < b, c",
+			want: "a\nb > a\nc > a\n",
+		},
+		{
+			name: "successors",
+			src:  "a > b, c",
+			want: "a > b, c\nb\nc\n",
+		},
+		{
+			name: "comments and blank lines are ignored",
+			src: `
+				# stack a must run after stack b
+				a > b
+
+			`,
+			want: "a > b\nb\n",
+		},
+		{
+			name: "unknown references are auto-created",
+			src:  "a < b",
+			want: "a\nb > a\n",
+		},
+	}
+
+	for _, tc := range tcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := Parse(tc.src)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tc.src, err)
+			}
+
+			got := d.String()
+			if got != tc.want {
+				t.Fatalf("String() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAutoCreatedNodesHaveValues(t *testing.T) {
+	// Auto-created nodes get a nil value, but they must still be real nodes,
+	// regardless of which side of the edge auto-created them.
+	for _, src := range []string{"a < b", "a > b"} {
+		d, err := Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", src, err)
+		}
+		if _, err := d.Node("b"); err != nil {
+			t.Errorf("Parse(%q).Node(b) = %v, want no error", src, err)
+		}
+	}
+}
+
+func TestParseDuplicateNode(t *testing.T) {
+	_, err := Parse(`
+		a > b
+		a > c
+	`)
+	if !errors.Is(err, ErrDuplicateNode) {
+		t.Fatalf("got %v, want ErrDuplicateNode", err)
+	}
+}
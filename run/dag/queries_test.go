@@ -0,0 +1,58 @@
+// Copyright 2021 Mineiros GmbH
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransposeAncestorsDescendantsReachable(t *testing.T) {
+	// a -> b -> d
+	//   -> c -> d
+	d, err := Parse(`
+		a > b, c
+		b > d
+		c > d
+	`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	ancestors := d.AncestorsOf("d")
+	if want := (idList{"a", "b", "c"}); !reflect.DeepEqual(idList(ancestors), want) {
+		t.Errorf("AncestorsOf(d) = %v, want %v", ancestors, want)
+	}
+
+	descendants := d.DescendantsOf("a")
+	if want := (idList{"b", "c", "d"}); !reflect.DeepEqual(idList(descendants), want) {
+		t.Errorf("DescendantsOf(a) = %v, want %v", descendants, want)
+	}
+
+	if !d.Reachable("a", "d") {
+		t.Errorf("Reachable(a, d) = false, want true")
+	}
+	if d.Reachable("d", "a") {
+		t.Errorf("Reachable(d, a) = true, want false")
+	}
+	if !d.Reachable("a", "a") {
+		t.Errorf("Reachable(a, a) = false, want true")
+	}
+
+	transposed := d.Transpose()
+	if got := sortedIds(transposed.ChildrenOf("d")); !reflect.DeepEqual(got, idList{"b", "c"}) {
+		t.Errorf("Transpose().ChildrenOf(d) = %v, want [b c]", got)
+	}
+}